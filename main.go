@@ -1,11 +1,17 @@
-// swagger-mixin writes the mixed spec to stdout and exits with
-// non-zero iff expected collision count of mixed-in paths,
-// definitions, parameters and responses does not match the number
-// given with the -c option (which defaults to zero if unspecified)
+// swagger-mixin writes the mixed spec to stdout (or to -o/--output)
+// and exits with non-zero iff expected collision count of mixed-in
+// paths, definitions, parameters, responses, tags and security
+// definitions (for OpenAPI 3.x: paths, components and tags) does not
+// match the number given with the -c option (which defaults to zero if
+// unspecified)
 //
-// The given Swagger 2.0 files can be YAML or JSON.  YAML input
-// requires a .yml or .yaml filename suffix; everything else is
-// considered to be in JSON format.  Always writes result in JSON.
+// The given Swagger 2.0 or OpenAPI 3.x files can be YAML or JSON.
+// YAML input requires a .yml or .yaml filename suffix; everything else
+// is considered to be in JSON format. Output defaults to indented
+// JSON; use --format yaml for YAML, --compact for unindented JSON, and
+// --keep-spec-order to preserve the input files' key order within
+// definitions, parameters, responses and paths (Swagger 2.0 only).
+// Nested objects keep the default ordering either way.
 //
 // This is a proof of concept for a PR to github.com/go-swagger/go-swagger
 //
@@ -29,7 +35,12 @@ import (
 )
 
 var opts struct {
-	ExpectedCollisionCount uint `short:"c" description:"expected # of rejected mixin paths, defs, etc due to existing key. Non-zero exit if does match actual."`
+	ExpectedCollisionCount uint   `short:"c" description:"expected # of rejected mixin paths, defs, etc due to existing key. Non-zero exit if does match actual."`
+	RenameCollisions       bool   `long:"rename-collisions" description:"rename colliding definitions/parameters/responses (fixing up $refs) instead of skipping them"`
+	Format                 string `long:"format" description:"output format" default:"json" choice:"json" choice:"yaml"`
+	Compact                bool   `long:"compact" description:"produce compact JSON output (no indentation); ignored for yaml"`
+	KeepSpecOrder          bool   `long:"keep-spec-order" description:"preserve the input spec files' key order within definitions, parameters, responses and paths instead of the default alphabetic ordering"`
+	Output                 string `short:"o" long:"output" description:"write merged spec to this file instead of stdout"`
 }
 
 func main() {
@@ -40,10 +51,30 @@ func main() {
 	}
 
 	if len(args) < 2 {
-		log.Fatalln("Nothing to do. Need some swagger files to merge.\nUSAGE: swagger-mixin [-c <expected#Collisions>] <primary-swagger-file> <mixin-swagger-file>...")
+		log.Fatalln("Nothing to do. Need some swagger files to merge.\nUSAGE: swagger-mixin [-c <expected#Collisions>] [--rename-collisions] [--format json|yaml] [--compact] [--keep-spec-order] [-o <file>] <primary-swagger-file> <mixin-swagger-file>...")
 	}
 
-	collisions, err := mixer.MixinFiles(args[0], args[1:], os.Stdout)
+	out := os.Stdout
+	if opts.Output != "" {
+		f, err := os.Create(opts.Output)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	mixinOpts := mixer.MixinOpts{CollisionMode: mixer.SkipOnCollision}
+	if opts.RenameCollisions {
+		mixinOpts.CollisionMode = mixer.RenameOnCollision
+	}
+	outOpts := mixer.MixinFilesOpts{
+		Format:        mixer.OutputFormat(opts.Format),
+		Compact:       opts.Compact,
+		KeepSpecOrder: opts.KeepSpecOrder,
+	}
+
+	collisions, _, err := mixer.MixinFiles(args[0], args[1:], out, mixinOpts, outOpts)
 
 	if err != nil {
 		log.Fatalln(err)
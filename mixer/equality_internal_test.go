@@ -0,0 +1,119 @@
+package mixer
+
+import (
+	"testing"
+
+	"github.com/go-openapi/spec"
+)
+
+func newTestSwagger(defs spec.Definitions) *spec.Swagger {
+	return &spec.Swagger{
+		SwaggerProps: spec.SwaggerProps{
+			Swagger:     "2.0",
+			Paths:       &spec.Paths{Paths: map[string]spec.PathItem{}},
+			Definitions: defs,
+		},
+	}
+}
+
+func TestMixinWithOptionsDeepEqualityCollisions(t *testing.T) {
+	fooString := spec.StringProperty()
+	fooNumber := spec.Int64Property()
+
+	primary := newTestSwagger(spec.Definitions{"Foo": *fooString})
+
+	mixinSame := newTestSwagger(spec.Definitions{"Foo": *fooString})
+	mixinDifferent := newTestSwagger(spec.Definitions{"Foo": *fooNumber})
+
+	_, collisions := MixinWithOptions(MixinOpts{CollisionMode: ContinueOnCollision}, primary, mixinSame, mixinDifferent)
+
+	if len(collisions) != 2 {
+		t.Fatalf("expected 2 collisions, got %v: %+v", len(collisions), collisions)
+	}
+	if !collisions[0].Equal {
+		t.Errorf("expected identical 'Foo' definition collision to be Equal, got %+v", collisions[0])
+	}
+	if collisions[1].Equal {
+		t.Errorf("expected differing 'Foo' definition collision to NOT be Equal, got %+v", collisions[1])
+	}
+}
+
+func TestMixinWithOptionsRenameOnCollisionRecordsEqualDuplicates(t *testing.T) {
+	fooString := spec.StringProperty()
+
+	primary := newTestSwagger(spec.Definitions{"Foo": *fooString})
+	mixinSame := newTestSwagger(spec.Definitions{"Foo": *fooString})
+
+	_, collisions := MixinWithOptions(MixinOpts{CollisionMode: RenameOnCollision}, primary, mixinSame)
+
+	if len(collisions) != 1 {
+		t.Fatalf("expected 1 collision for the structurally identical duplicate, got %v: %+v", len(collisions), collisions)
+	}
+	if !collisions[0].Equal {
+		t.Errorf("expected identical 'Foo' definition collision to be Equal, got %+v", collisions[0])
+	}
+	if _, renamed := primary.Definitions["FooMixin0"]; renamed {
+		t.Errorf("structurally identical duplicate should have been dropped, not renamed")
+	}
+}
+
+func TestMixinWithOptionsRenameOnCollisionAvoidsClobberingMixinsOwnKey(t *testing.T) {
+	// mixin's "Foo" collides with (and differs from) primary's "Foo",
+	// so it gets renamed to "FooMixin0" -- but mixin already has its
+	// own, unrelated "FooMixin0" entry (e.g. from a prior
+	// rename-on-collision merge). The rename must not silently
+	// overwrite it.
+	primaryFoo := spec.Int64Property()
+	mixinFoo := spec.Int64Property()
+	mixinFoo.Description = "differs from primary's Foo"
+	mixinFooMixin0 := spec.BooleanProperty()
+
+	primary := newTestSwagger(spec.Definitions{"Foo": *primaryFoo})
+	mixin := newTestSwagger(spec.Definitions{
+		"Foo":       *mixinFoo,
+		"FooMixin0": *mixinFooMixin0,
+	})
+
+	_, collisions := MixinWithOptions(MixinOpts{CollisionMode: RenameOnCollision}, primary, mixin)
+
+	for _, c := range collisions {
+		if c.Section == "definitions" && c.Key == "FooMixin0" {
+			t.Errorf("mixin's own unrelated 'FooMixin0' should not be treated as a collision, got %+v", c)
+		}
+	}
+
+	got, ok := primary.Definitions["FooMixin0"]
+	if !ok {
+		t.Fatal("expected mixin's original 'FooMixin0' to survive the merge")
+	}
+	if !specEqual(got, *mixinFooMixin0) {
+		t.Errorf("mixin's own 'FooMixin0' was clobbered by the renamed 'Foo': got %+v, want %+v", got, *mixinFooMixin0)
+	}
+
+	renamedFoo, ok := primary.Definitions["FooMixin0_1"]
+	if !ok {
+		t.Fatal("expected colliding 'Foo' to be renamed to 'FooMixin0_1' since 'FooMixin0' was already taken")
+	}
+	if !specEqual(renamedFoo, *mixinFoo) {
+		t.Errorf("renamed 'Foo' entry doesn't match mixin's original 'Foo': got %+v, want %+v", renamedFoo, *mixinFoo)
+	}
+}
+
+func TestMixinWithOptionsNilSecurityDefinitions(t *testing.T) {
+	// primary has no securityDefinitions block at all (nil map); merging
+	// a mixin that has one must initialize it instead of panicking on a
+	// nil map write.
+	primary := newTestSwagger(nil)
+	mixin := newTestSwagger(nil)
+	mixin.SecurityDefinitions = map[string]*spec.SecurityScheme{
+		"ApiKeyAuth": spec.APIKeyAuth("X-API-Key", "header"),
+	}
+
+	collisionCount, _ := MixinWithOptions(MixinOpts{}, primary, mixin)
+	if collisionCount != 0 {
+		t.Fatalf("expected 0 collisions, got %v", collisionCount)
+	}
+	if _, ok := primary.SecurityDefinitions["ApiKeyAuth"]; !ok {
+		t.Errorf("expected mixin's 'ApiKeyAuth' securityDefinition to be merged into primary")
+	}
+}
@@ -1,4 +1,7 @@
-// mixer provides functions to merge Swagger 2.0 specs into one spec
+// mixer provides functions to merge Swagger 2.0 specs into one spec.
+// OpenAPI 3.x documents are also supported, via MixinV3; MixinFiles
+// autodetects which version a given primary file is and dispatches
+// accordingly.
 //
 // Use cases include adding independently versioned metadata APIs to
 // application APIs for microservices.
@@ -16,7 +19,6 @@
 package mixer
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -25,43 +27,145 @@ import (
 	"github.com/go-openapi/spec"
 )
 
-// Mixin modifies the primary swagger spec by adding the paths and
-// definitions from the mixin specs. Top level parameters and
-// responses from the mixins are also carried over. Operation id
-// collisions are avoided by appending "Mixin<N>" but only if
-// needed. No other parts of primary are modified. Consider calling
-// FixEmptyResponseDescriptions() on the modified primary if you read
-// them from storage and they are valid to start with.
+// CollisionMode selects how Mixin and MixinWithOptions behave when a
+// mixin entry collides with one already present in primary (or a
+// higher priority mixin).
+type CollisionMode int
+
+const (
+	// SkipOnCollision is the original Mixin behavior: the colliding
+	// mixin entry is dropped and a warning is logged for each
+	// occurrence.
+	SkipOnCollision CollisionMode = iota
+
+	// ContinueOnCollision also drops the colliding mixin entry, but
+	// does not log a warning for each occurrence; only collisions in
+	// "definitions", "parameters", "responses" and "paths" whose
+	// entries are not structurally identical are still logged. Callers
+	// are expected to inspect the returned []Collision slice instead
+	// and decide per-section what to do.
+	ContinueOnCollision
+
+	// RenameOnCollision renames colliding "definitions", "parameters"
+	// and "responses" entries (and rewrites every $ref pointing at
+	// them) to a unique "<Name>Mixin<N>" instead of skipping them.
+	// Structurally identical collisions are still dropped in favor of
+	// reusing the existing name. "paths" collisions are unaffected,
+	// since two path templates can't be merged by renaming.
+	RenameOnCollision
+)
+
+// Collision describes a single mixin entry that was rejected because
+// an entry of the same name already existed in primary or a higher
+// priority mixin.
+type Collision struct {
+	// Section is the part of the spec the collision occurred in, e.g.
+	// "definitions", "paths", "parameters", "responses", "tags" or
+	// "securityDefinitions".
+	Section string
+	// Key is the name (or path) of the colliding entry.
+	Key string
+	// Reason is a short human readable explanation of the collision.
+	Reason string
+	// Equal reports whether the mixin's entry is structurally
+	// identical (per specEqual) to the one already in primary. Only
+	// meaningful for "definitions", "parameters", "responses" and
+	// "paths" collisions, where a deep compare is actually performed;
+	// always false for other sections. An Equal collision is a
+	// harmless duplicate; a non-Equal one means primary and a mixin
+	// disagree on the shape of something sharing a name, which Mixin
+	// silently used to hide behind a single log line.
+	Equal bool
+}
+
+// MixinOpts controls the behavior of MixinWithOptions.
+type MixinOpts struct {
+	// CollisionMode selects how collisions are resolved. The zero
+	// value is SkipOnCollision, matching the behavior of Mixin.
+	CollisionMode CollisionMode
+}
+
+// Mixin modifies the primary swagger spec by adding the paths,
+// definitions, parameters, responses, security definitions, security
+// requirements, tags, schemes, consumes and produces from the mixin
+// specs. Operation id collisions are avoided by appending "Mixin<N>"
+// but only if needed. No other parts of primary are modified. Consider
+// calling FixEmptyResponseDescriptions() on the modified primary if
+// you read them from storage and they are valid to start with.
 //
-// Entries in "paths", "definitions", "parameters" and "responses" are
-// added to the primary in the order of the given mixins. If the entry
-// already exists in primary it is skipped with a warning message.
+// Entries in "paths", "definitions", "parameters", "responses" and
+// "securityDefinitions" are added to the primary in the order of the
+// given mixins. If the entry already exists in primary it is skipped;
+// for "definitions", "parameters", "responses" and "paths" a deep
+// structural compare is done first, so a harmless duplicate (same name,
+// identical shape) is logged as a plain skip while a genuine collision
+// (same name, different shape) is logged as an error, since merging it
+// would silently lose one side's definition.
 //
 // The count of skipped entries (from collisions) is returned so any
 // deviation from the number expected can flag warning in your build
 // scripts. Carefully review the collisions before accepting them;
-// consider renaming things if possible.
+// consider renaming things if possible, or use MixinWithOptions with
+// RenameOnCollision.
 //
 // No normalization of any keys takes place (paths, type defs,
 // etc). Ensure they are canonical if your downstream tools do
 // key normalization of any form.
+//
+// Mixin is a thin wrapper around MixinWithOptions using
+// SkipOnCollision, kept for backwards compatibility. Use
+// MixinWithOptions directly for programmatic access to the collision
+// list.
 func Mixin(primary *spec.Swagger, mixins ...*spec.Swagger) uint {
-	var skipped uint
+	n, _ := MixinWithOptions(MixinOpts{CollisionMode: SkipOnCollision}, primary, mixins...)
+	return n
+}
+
+// MixinWithOptions is the full featured version of Mixin. It merges
+// the same sections as Mixin but additionally lets the caller select a
+// CollisionMode via opts, and always returns the full list of
+// collisions encountered so tooling can decide per-section what to do
+// about them instead of relying solely on the count.
+func MixinWithOptions(opts MixinOpts, primary *spec.Swagger, mixins ...*spec.Swagger) (uint, []Collision) {
+	var collisions []Collision
+	collide := func(section, key, reason string) {
+		collisions = append(collisions, Collision{Section: section, Key: key, Reason: reason})
+		if opts.CollisionMode == SkipOnCollision {
+			log.Printf("%v entry '%v' %v, skipping\n", section, key, reason)
+		}
+	}
+	// collideChecked is collide, but for sections where a deep
+	// structural compare between the existing and incoming entry is
+	// possible. A structurally identical collision is a harmless
+	// duplicate; a differing one is a genuine, and potentially
+	// dangerous, name collision that gets logged regardless of
+	// CollisionMode instead of being silently dropped.
+	collideChecked := func(section, key, reason string, existing, incoming interface{}) {
+		equal := specEqual(existing, incoming)
+		collisions = append(collisions, Collision{Section: section, Key: key, Reason: reason, Equal: equal})
+		switch {
+		case !equal:
+			log.Printf("ERROR: %v entry '%v' %v but is NOT structurally identical to it; keeping the existing one and dropping the mixin's, review for possible data loss\n", section, key, reason)
+		case opts.CollisionMode == SkipOnCollision:
+			log.Printf("%v entry '%v' %v, skipping\n", section, key, reason)
+		}
+	}
+
 	opIds := getOpIds(primary)
 	for i, m := range mixins {
+		if opts.CollisionMode == RenameOnCollision {
+			collisions = append(collisions, applyRenameOnCollision(primary, m, i)...)
+		}
 		for k, v := range m.Definitions {
-			// assume name collisions represent IDENTICAL type. careful.
-			if _, exists := primary.Definitions[k]; exists {
-				log.Printf("definitions entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
-				skipped++
+			if existing, exists := primary.Definitions[k]; exists {
+				collideChecked("definitions", k, "already exists in primary or higher priority mixin", existing, v)
 				continue
 			}
 			primary.Definitions[k] = v
 		}
 		for k, v := range m.Paths.Paths {
-			if _, exists := primary.Paths.Paths[k]; exists {
-				log.Printf("paths entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
-				skipped++
+			if existing, exists := primary.Paths.Paths[k]; exists {
+				collideChecked("paths", k, "already exists in primary or higher priority mixin", existing, v)
 				continue
 			}
 
@@ -81,64 +185,149 @@ func Mixin(primary *spec.Swagger, mixins ...*spec.Swagger) uint {
 			primary.Paths.Paths[k] = v
 		}
 		for k, v := range m.Parameters {
-			// could try to rename on conflict but would
-			// have to fix $refs in the mixin. Complain
-			// for now
-			if _, exists := primary.Parameters[k]; exists {
-				log.Printf("top level parameters entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
-				skipped++
+			if existing, exists := primary.Parameters[k]; exists {
+				collideChecked("parameters", k, "already exists in primary or higher priority mixin", existing, v)
 				continue
 			}
 			primary.Parameters[k] = v
 		}
 		for k, v := range m.Responses {
-			// could try to rename on conflict but would
-			// have to fix $refs in the mixin. Complain
-			// for now
-			if _, exists := primary.Responses[k]; exists {
-				log.Printf("top level responses entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
-				skipped++
+			if existing, exists := primary.Responses[k]; exists {
+				collideChecked("responses", k, "already exists in primary or higher priority mixin", existing, v)
 				continue
 			}
 			primary.Responses[k] = v
 		}
+		for k, v := range m.SecurityDefinitions {
+			if _, exists := primary.SecurityDefinitions[k]; exists {
+				collide("securityDefinitions", k, "already exists in primary or higher priority mixin")
+				continue
+			}
+			if primary.SecurityDefinitions == nil {
+				primary.SecurityDefinitions = make(map[string]*spec.SecurityScheme)
+			}
+			primary.SecurityDefinitions[k] = v
+		}
+		for _, t := range m.Tags {
+			if hasTag(primary.Tags, t.Name) {
+				collide("tags", t.Name, "already exists in primary or higher priority mixin")
+				continue
+			}
+			primary.Tags = append(primary.Tags, t)
+		}
+		primary.Security = append(primary.Security, m.Security...)
+		primary.Schemes = mergeStringSlice(primary.Schemes, m.Schemes)
+		primary.Consumes = mergeStringSlice(primary.Consumes, m.Consumes)
+		primary.Produces = mergeStringSlice(primary.Produces, m.Produces)
 	}
-	return skipped
+	return uint(len(collisions)), collisions
+}
+
+// hasTag reports whether tags already contains a tag with the given name.
+func hasTag(tags []spec.Tag, name string) bool {
+	for _, t := range tags {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeStringSlice appends the values from add to base that are not
+// already present in base, preserving the order both were given in.
+func mergeStringSlice(base []string, add []string) []string {
+	for _, v := range add {
+		found := false
+		for _, existing := range base {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			base = append(base, v)
+		}
+	}
+	return base
 }
 
 // MixinFiles is a convenience function for Mixin that reads the given
 // swagger files, adds the mixins to primary, calls
-// FixEmptyResponseDescriptions on the primary, and writes the primary
-// with mixins to the given writer in JSON.  Returns the number of
-// collsions that occured from mixins and any error.
-func MixinFiles(primaryFile string, mixinFiles []string, w io.Writer) (uint, error) {
+// FixEmptyResponseDescriptions on the primary, and writes the merged
+// primary to the given writer according to outOpts (JSON or YAML,
+// compact or indented, original or default key order). Returns the
+// number of collisions, the full collision list, and any error. For
+// OpenAPI 3.x documents only the count is returned; the collision list
+// is nil.
+//
+// The primary file is sniffed for its "openapi" vs "swagger" top level
+// field; OpenAPI 3.x documents are dispatched to MixinV3 via
+// mixinFilesV3, everything else is treated as Swagger 2.0. Every mixin
+// file is sniffed the same way and must match the primary's version;
+// mixing Swagger 2.0 and OpenAPI 3.x files is not supported and returns
+// an error. MixinFilesOpts.KeepSpecOrder is only honored for Swagger
+// 2.0; OpenAPI 3.x primaries return an error if it is set.
+func MixinFiles(primaryFile string, mixinFiles []string, w io.Writer, mixinOpts MixinOpts, outOpts MixinFilesOpts) (uint, []Collision, error) {
+	isV3, err := isOpenAPI3(primaryFile)
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, mixinFile := range mixinFiles {
+		mixinIsV3, err := isOpenAPI3(mixinFile)
+		if err != nil {
+			return 0, nil, err
+		}
+		if mixinIsV3 != isV3 {
+			return 0, nil, fmt.Errorf("%v is %v but primary %v is %v; mixing Swagger 2.0 and OpenAPI 3.x files is not supported", mixinFile, specVersionName(mixinIsV3), primaryFile, specVersionName(isV3))
+		}
+	}
+	if isV3 {
+		if outOpts.KeepSpecOrder {
+			return 0, nil, fmt.Errorf("keep-spec-order is not supported for OpenAPI 3.x documents")
+		}
+		collisions, err := mixinFilesV3(primaryFile, mixinFiles, w, outOpts)
+		return collisions, nil, err
+	}
+	return mixinFilesV2(primaryFile, mixinFiles, w, mixinOpts, outOpts)
+}
+
+// specVersionName returns a human readable name for the spec version
+// isOpenAPI3 sniffed, for use in error messages.
+func specVersionName(isV3 bool) string {
+	if isV3 {
+		return "OpenAPI 3.x"
+	}
+	return "Swagger 2.0"
+}
+
+func mixinFilesV2(primaryFile string, mixinFiles []string, w io.Writer, opts MixinOpts, outOpts MixinFilesOpts) (uint, []Collision, error) {
 
 	primaryDoc, err := loads.Spec(primaryFile)
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 	primary := primaryDoc.Spec()
 
+	rawSources := [][]byte{primaryDoc.Raw()}
+
 	var mixins []*spec.Swagger
 	for _, mixinFile := range mixinFiles {
 		mixin, err := loads.Spec(mixinFile)
 		if err != nil {
-			return 0, err
+			return 0, nil, err
 		}
 		mixins = append(mixins, mixin.Spec())
+		rawSources = append(rawSources, mixin.Raw())
 	}
 
-	collisions := Mixin(primary, mixins...)
+	collisionCount, collisions := MixinWithOptions(opts, primary, mixins...)
 	FixEmptyResponseDescriptions(primary)
 
-	bs, err := json.MarshalIndent(primary, "", "  ")
-	if err != nil {
-		return 0, err
+	if err := writeMerged(primary, w, outOpts, rawSources); err != nil {
+		return 0, nil, err
 	}
 
-	w.Write(bs)
-
-	return collisions, nil
+	return collisionCount, collisions, nil
 }
 
 // FixEmptyResponseDescriptions replaces empty ("") response
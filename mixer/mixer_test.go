@@ -1,8 +1,11 @@
 package mixer_test
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"reflect"
 	"testing"
 
 	"github.com/go-openapi/errors"
@@ -10,6 +13,7 @@ import (
 	"github.com/go-openapi/strfmt"
 	"github.com/go-openapi/validate"
 	"github.com/msample/swagger-mixin/mixer"
+	yaml "gopkg.in/yaml.v2"
 )
 
 func TestMixin(t *testing.T) {
@@ -51,12 +55,72 @@ func TestMixin(t *testing.T) {
 
 }
 
+func TestMixinWithOptionsContinueOnCollision(t *testing.T) {
+
+	primaryDoc, err := loads.Spec("../test-data/s1.yml")
+	if err != nil {
+		t.Fatalf("Could not load ../test-data/s1.yml: %v\n", err)
+	}
+	mixinDoc1, err := loads.Spec("../test-data/s2.yml")
+	if err != nil {
+		t.Fatalf("Could not load ../test-data/s2.yml: %v\n", err)
+	}
+	mixinDoc2, err := loads.Spec("../test-data/s3.yml")
+	if err != nil {
+		t.Fatalf("Could not load ../test-data/s3.yml: %v\n", err)
+	}
+
+	primary := primaryDoc.Spec()
+	opts := mixer.MixinOpts{CollisionMode: mixer.ContinueOnCollision}
+	collisionCount, collisions := mixer.MixinWithOptions(opts, primary, mixinDoc1.Spec(), mixinDoc2.Spec())
+	if collisionCount != 12 {
+		t.Errorf("TestMixinWithOptionsContinueOnCollision: Expected 12 collisions, got %v\n", collisionCount)
+	}
+	if uint(len(collisions)) != collisionCount {
+		t.Errorf("TestMixinWithOptionsContinueOnCollision: Expected len(collisions) == collisionCount, got %v != %v\n", len(collisions), collisionCount)
+	}
+	for _, c := range collisions {
+		if c.Section == "" || c.Key == "" {
+			t.Errorf("TestMixinWithOptionsContinueOnCollision: Collision missing Section or Key: %+v\n", c)
+		}
+	}
+}
+
+func TestMixinFilesWithOptionsRenameCollisions(t *testing.T) {
+	f, err := ioutil.TempFile("", "mixerTest-rename-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mixinOpts := mixer.MixinOpts{CollisionMode: mixer.RenameOnCollision}
+	_, collisions, err := mixer.MixinFiles("../test-data/s1.yml", []string{"../test-data/s2.yml", "../test-data/s3.yml"}, f, mixinOpts, mixer.MixinFilesOpts{})
+	if err != nil {
+		t.Errorf("TestMixinFilesWithOptionsRenameCollisions: got error: %v\n", err)
+	}
+	for _, c := range collisions {
+		if c.Section == "definitions" || c.Section == "parameters" || c.Section == "responses" {
+			t.Errorf("TestMixinFilesWithOptionsRenameCollisions: %v collision should have been renamed, not skipped: %+v\n", c.Section, c)
+		}
+	}
+	specDoc, err := loads.Spec(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := validate.Spec(specDoc, strfmt.Default)
+	if result != nil {
+		str := fmt.Sprintf("The spec at %q is invalid after rename-on-collision merge:\n", f.Name())
+		for _, desc := range result.(*errors.CompositeError).Errors {
+			str += fmt.Sprintf("- %s\n", desc)
+		}
+		t.Error(str)
+	}
+}
+
 func TestMixinFiles(t *testing.T) {
 	f, err := ioutil.TempFile("", "mixerTest-")
 	if err != nil {
 		t.Fatal(err)
 	}
-	collisions, err := mixer.MixinFiles("../test-data/s1.yml", []string{"../test-data/s2.yml", "../test-data/s3.yml"}, f)
+	collisions, _, err := mixer.MixinFiles("../test-data/s1.yml", []string{"../test-data/s2.yml", "../test-data/s3.yml"}, f, mixer.MixinOpts{}, mixer.MixinFilesOpts{})
 	if err != nil {
 		t.Errorf("TestMixinFiles: got error: %v\n", err)
 	}
@@ -76,3 +140,189 @@ func TestMixinFiles(t *testing.T) {
 		t.Error(str)
 	}
 }
+
+func TestMixinTagsSecurityDefsAndSchemes(t *testing.T) {
+	primaryDoc, err := loads.Spec("../test-data/sec-primary.yml")
+	if err != nil {
+		t.Fatalf("Could not load ../test-data/sec-primary.yml: %v\n", err)
+	}
+	mixinDoc, err := loads.Spec("../test-data/sec-mixin.yml")
+	if err != nil {
+		t.Fatalf("Could not load ../test-data/sec-mixin.yml: %v\n", err)
+	}
+
+	primary := primaryDoc.Spec()
+	opts := mixer.MixinOpts{CollisionMode: mixer.ContinueOnCollision}
+	collisionCount, collisions := mixer.MixinWithOptions(opts, primary, mixinDoc.Spec())
+
+	if collisionCount != 2 {
+		t.Fatalf("TestMixinTagsSecurityDefsAndSchemes: expected 2 collisions (tag + securityDefinitions), got %v: %+v\n", collisionCount, collisions)
+	}
+	for _, c := range collisions {
+		if c.Section != "tags" && c.Section != "securityDefinitions" {
+			t.Errorf("TestMixinTagsSecurityDefsAndSchemes: unexpected collision section %+v\n", c)
+		}
+	}
+
+	if len(primary.Tags) != 2 {
+		t.Errorf("TestMixinTagsSecurityDefsAndSchemes: expected 2 tags (TagA, TagB) after merge, got %v: %+v\n", len(primary.Tags), primary.Tags)
+	}
+
+	if len(primary.SecurityDefinitions) != 2 {
+		t.Errorf("TestMixinTagsSecurityDefsAndSchemes: expected 2 securityDefinitions (ApiKeyAuth, OAuth2) after merge, got %v\n", len(primary.SecurityDefinitions))
+	}
+	if _, ok := primary.SecurityDefinitions["OAuth2"]; !ok {
+		t.Errorf("TestMixinTagsSecurityDefsAndSchemes: expected mixin's 'OAuth2' securityDefinition to be merged in")
+	}
+
+	if len(primary.Security) != 2 {
+		t.Errorf("TestMixinTagsSecurityDefsAndSchemes: expected primary's and mixin's security requirements both present, got %v: %+v\n", len(primary.Security), primary.Security)
+	}
+
+	wantSchemes := []string{"https", "http"}
+	if !stringSlicesEqual(primary.Schemes, wantSchemes) {
+		t.Errorf("TestMixinTagsSecurityDefsAndSchemes: expected schemes %v, got %v\n", wantSchemes, primary.Schemes)
+	}
+
+	wantConsumes := []string{"application/json", "application/xml"}
+	if !stringSlicesEqual(primary.Consumes, wantConsumes) {
+		t.Errorf("TestMixinTagsSecurityDefsAndSchemes: expected consumes %v, got %v\n", wantConsumes, primary.Consumes)
+	}
+
+	wantProduces := []string{"application/json"}
+	if !stringSlicesEqual(primary.Produces, wantProduces) {
+		t.Errorf("TestMixinTagsSecurityDefsAndSchemes: expected produces %v (deduplicated), got %v\n", wantProduces, primary.Produces)
+	}
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMixinFilesYAMLFormat(t *testing.T) {
+	var buf bytes.Buffer
+	opts := mixer.MixinFilesOpts{Format: mixer.YAMLFormat}
+	_, _, err := mixer.MixinFiles("../test-data/s1.yml", []string{"../test-data/s2.yml", "../test-data/s3.yml"}, &buf, mixer.MixinOpts{}, opts)
+	if err != nil {
+		t.Fatalf("TestMixinFilesYAMLFormat: got error: %v\n", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := yaml.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("TestMixinFilesYAMLFormat: output is not valid YAML: %v\noutput:\n%s", err, buf.String())
+	}
+	if decoded["swagger"] != "2.0" {
+		t.Errorf("TestMixinFilesYAMLFormat: expected top level 'swagger: \"2.0\"', got %+v\n", decoded["swagger"])
+	}
+	if bytes.Contains(buf.Bytes(), []byte("{")) {
+		t.Errorf("TestMixinFilesYAMLFormat: expected YAML output, got what looks like JSON:\n%s", buf.String())
+	}
+}
+
+func TestMixinFilesCompact(t *testing.T) {
+	var indented, compact bytes.Buffer
+	_, _, err := mixer.MixinFiles("../test-data/s1.yml", []string{"../test-data/s2.yml", "../test-data/s3.yml"}, &indented, mixer.MixinOpts{}, mixer.MixinFilesOpts{})
+	if err != nil {
+		t.Fatalf("TestMixinFilesCompact: got error building indented output: %v\n", err)
+	}
+	_, _, err = mixer.MixinFiles("../test-data/s1.yml", []string{"../test-data/s2.yml", "../test-data/s3.yml"}, &compact, mixer.MixinOpts{}, mixer.MixinFilesOpts{Compact: true})
+	if err != nil {
+		t.Fatalf("TestMixinFilesCompact: got error building compact output: %v\n", err)
+	}
+
+	if bytes.Contains(compact.Bytes(), []byte("\n")) {
+		t.Errorf("TestMixinFilesCompact: expected no newlines in compact output, got:\n%s", compact.String())
+	}
+	if !bytes.Contains(indented.Bytes(), []byte("\n")) {
+		t.Errorf("TestMixinFilesCompact: expected the non-compact output to be indented")
+	}
+
+	var wantCompact, gotCompact interface{}
+	if err := json.Unmarshal(indented.Bytes(), &wantCompact); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(compact.Bytes(), &gotCompact); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(wantCompact, gotCompact) {
+		t.Errorf("TestMixinFilesCompact: compact and indented output decode to different values")
+	}
+}
+
+func TestMixinFilesKeepSpecOrder(t *testing.T) {
+	var buf bytes.Buffer
+	opts := mixer.MixinFilesOpts{KeepSpecOrder: true}
+	_, _, err := mixer.MixinFiles("../test-data/s1.yml", []string{"../test-data/s2.yml", "../test-data/s3.yml"}, &buf, mixer.MixinOpts{}, opts)
+	if err != nil {
+		t.Fatalf("TestMixinFilesKeepSpecOrder: got error: %v\n", err)
+	}
+
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &top); err != nil {
+		t.Fatal(err)
+	}
+	defs, ok := top["definitions"]
+	if !ok {
+		t.Fatal("TestMixinFilesKeepSpecOrder: expected a 'definitions' section in the output")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(defs))
+	if _, err := dec.Token(); err != nil { // consume '{'
+		t.Fatal(err)
+	}
+	var keys []string
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, keyTok.(string))
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// s1.yml's own definitions ("D1", "D2") must come first, in the
+	// order they appear in s1.yml, ahead of the keys alphabetic
+	// encoding/json would otherwise produce.
+	if len(keys) < 2 || keys[0] != "D1" || keys[1] != "D2" {
+		t.Errorf("TestMixinFilesKeepSpecOrder: expected primary's definitions ('D1', 'D2') first, got %v\n", keys)
+	}
+}
+
+func TestMixinFilesKeepSpecOrderUnsupportedForV3(t *testing.T) {
+	var buf bytes.Buffer
+	opts := mixer.MixinFilesOpts{KeepSpecOrder: true}
+	_, _, err := mixer.MixinFiles("../test-data/v3-primary.yml", []string{"../test-data/v3-mixin1.yml"}, &buf, mixer.MixinOpts{}, opts)
+	if err == nil {
+		t.Error("TestMixinFilesKeepSpecOrderUnsupportedForV3: expected an error, got nil")
+	}
+}
+
+func TestMixinFilesVersionMismatch(t *testing.T) {
+	f, err := ioutil.TempFile("", "mixerTest-version-mismatch-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Swagger 2.0 primary, OpenAPI 3.x mixin.
+	_, _, err = mixer.MixinFiles("../test-data/s1.yml", []string{"../test-data/v3-primary.yml"}, f, mixer.MixinOpts{}, mixer.MixinFilesOpts{})
+	if err == nil {
+		t.Error("TestMixinFilesVersionMismatch: expected error mixing a Swagger 2.0 primary with an OpenAPI 3.x mixin, got nil")
+	}
+
+	// OpenAPI 3.x primary, Swagger 2.0 mixin.
+	_, _, err = mixer.MixinFiles("../test-data/v3-primary.yml", []string{"../test-data/s1.yml"}, f, mixer.MixinOpts{}, mixer.MixinFilesOpts{})
+	if err == nil {
+		t.Error("TestMixinFilesVersionMismatch: expected error mixing an OpenAPI 3.x primary with a Swagger 2.0 mixin, got nil")
+	}
+}
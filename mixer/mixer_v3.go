@@ -0,0 +1,270 @@
+package mixer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// versionProbe is used to sniff the "openapi" vs "swagger" top level
+// field of a spec file without fully parsing it.
+type versionProbe struct {
+	Swagger string `json:"swagger" yaml:"swagger"`
+	OpenAPI string `json:"openapi" yaml:"openapi"`
+}
+
+// isOpenAPI3 reports whether the given spec file is an OpenAPI 3.x
+// document, based on its top level "openapi" field. Swagger 2.0 (or
+// anything else) is reported as false.
+func isOpenAPI3(specFile string) (bool, error) {
+	bs, err := ioutil.ReadFile(specFile)
+	if err != nil {
+		return false, err
+	}
+
+	var p versionProbe
+	if strings.HasSuffix(specFile, ".yml") || strings.HasSuffix(specFile, ".yaml") {
+		if err := yaml.Unmarshal(bs, &p); err != nil {
+			return false, err
+		}
+	} else if err := json.Unmarshal(bs, &p); err != nil {
+		return false, err
+	}
+
+	return strings.HasPrefix(p.OpenAPI, "3."), nil
+}
+
+// MixinV3 modifies the primary OpenAPI 3.x document by adding the
+// paths, tags, servers and security from the mixin documents, along
+// with their components: schemas, parameters, responses,
+// requestBodies, securitySchemes, headers, examples, callbacks and
+// links. It mirrors the semantics of Mixin: entries are added in the
+// order of the given mixins and a name collision with primary (or a
+// higher priority mixin) causes the mixin's entry to be skipped with a
+// warning. Operation id collisions are avoided by appending "Mixin<N>"
+// but only if needed, same as Mixin. The count of skipped entries is
+// returned.
+func MixinV3(primary *openapi3.T, mixins ...*openapi3.T) uint {
+	var skipped uint
+
+	if primary.Components.Schemas == nil {
+		primary.Components.Schemas = make(openapi3.Schemas)
+	}
+	if primary.Components.Parameters == nil {
+		primary.Components.Parameters = make(openapi3.ParametersMap)
+	}
+	if primary.Components.Responses == nil {
+		primary.Components.Responses = make(openapi3.Responses)
+	}
+	if primary.Components.RequestBodies == nil {
+		primary.Components.RequestBodies = make(openapi3.RequestBodies)
+	}
+	if primary.Components.SecuritySchemes == nil {
+		primary.Components.SecuritySchemes = make(openapi3.SecuritySchemes)
+	}
+	if primary.Components.Headers == nil {
+		primary.Components.Headers = make(openapi3.Headers)
+	}
+	if primary.Components.Examples == nil {
+		primary.Components.Examples = make(openapi3.Examples)
+	}
+	if primary.Components.Callbacks == nil {
+		primary.Components.Callbacks = make(openapi3.Callbacks)
+	}
+	if primary.Components.Links == nil {
+		primary.Components.Links = make(openapi3.Links)
+	}
+	if primary.Paths == nil {
+		primary.Paths = make(openapi3.Paths)
+	}
+
+	opIds := getOpIds3(primary.Paths)
+	for i, m := range mixins {
+		if m.Components == nil {
+			// A mixin with no top level "components:" block at all is
+			// a perfectly valid "paths-only" OpenAPI 3.x document; treat
+			// it as having none of the sections below instead of
+			// dereferencing a nil *openapi3.Components.
+			m.Components = &openapi3.Components{}
+		}
+		for k, v := range m.Components.Schemas {
+			if _, exists := primary.Components.Schemas[k]; exists {
+				log.Printf("components.schemas entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
+				skipped++
+				continue
+			}
+			primary.Components.Schemas[k] = v
+		}
+		for k, v := range m.Components.Parameters {
+			if _, exists := primary.Components.Parameters[k]; exists {
+				log.Printf("components.parameters entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
+				skipped++
+				continue
+			}
+			primary.Components.Parameters[k] = v
+		}
+		for k, v := range m.Components.Responses {
+			if _, exists := primary.Components.Responses[k]; exists {
+				log.Printf("components.responses entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
+				skipped++
+				continue
+			}
+			primary.Components.Responses[k] = v
+		}
+		for k, v := range m.Components.RequestBodies {
+			if _, exists := primary.Components.RequestBodies[k]; exists {
+				log.Printf("components.requestBodies entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
+				skipped++
+				continue
+			}
+			primary.Components.RequestBodies[k] = v
+		}
+		for k, v := range m.Components.SecuritySchemes {
+			if _, exists := primary.Components.SecuritySchemes[k]; exists {
+				log.Printf("components.securitySchemes entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
+				skipped++
+				continue
+			}
+			primary.Components.SecuritySchemes[k] = v
+		}
+		for k, v := range m.Components.Headers {
+			if _, exists := primary.Components.Headers[k]; exists {
+				log.Printf("components.headers entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
+				skipped++
+				continue
+			}
+			primary.Components.Headers[k] = v
+		}
+		for k, v := range m.Components.Examples {
+			if _, exists := primary.Components.Examples[k]; exists {
+				log.Printf("components.examples entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
+				skipped++
+				continue
+			}
+			primary.Components.Examples[k] = v
+		}
+		for k, v := range m.Components.Callbacks {
+			if _, exists := primary.Components.Callbacks[k]; exists {
+				log.Printf("components.callbacks entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
+				skipped++
+				continue
+			}
+			primary.Components.Callbacks[k] = v
+		}
+		for k, v := range m.Components.Links {
+			if _, exists := primary.Components.Links[k]; exists {
+				log.Printf("components.links entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
+				skipped++
+				continue
+			}
+			primary.Components.Links[k] = v
+		}
+		for k, v := range m.Paths {
+			if _, exists := primary.Paths[k]; exists {
+				log.Printf("paths entry '%v' already exists in primary or higher priority mixin, skipping\n", k)
+				skipped++
+				continue
+			}
+
+			// OpenAPI requires that operationIds be unique within a
+			// document. If we find a collision we append "Mixin0" to
+			// the operationId we are adding, where 0 is mixin index.
+			// We assume that operationIds within all the provided
+			// specs are already unique.
+			for _, piop := range pathItemOps3(v) {
+				if opIds[piop.OperationID] {
+					piop.OperationID = fmt.Sprintf("%v%v%v", piop.OperationID, "Mixin", i)
+				}
+				opIds[piop.OperationID] = true
+			}
+			primary.Paths[k] = v
+		}
+		for _, t := range m.Tags {
+			if hasTag3(primary.Tags, t.Name) {
+				log.Printf("tags entry '%v' already exists in primary or higher priority mixin, skipping\n", t.Name)
+				skipped++
+				continue
+			}
+			primary.Tags = append(primary.Tags, t)
+		}
+		primary.Servers = append(primary.Servers, m.Servers...)
+		primary.Security = append(primary.Security, m.Security...)
+	}
+	return skipped
+}
+
+// hasTag3 reports whether tags already contains a tag with the given name.
+func hasTag3(tags openapi3.Tags, name string) bool {
+	for _, t := range tags {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getOpIds3 extracts all the paths.<path>.operationIds from the given
+// paths and returns them as the keys in a map with 'true' values.
+func getOpIds3(paths openapi3.Paths) map[string]bool {
+	rv := make(map[string]bool)
+	for _, v := range paths {
+		for _, op := range pathItemOps3(v) {
+			rv[op.OperationID] = true
+		}
+	}
+	return rv
+}
+
+func pathItemOps3(p *openapi3.PathItem) []*openapi3.Operation {
+	var rv []*openapi3.Operation
+	rv = appendOp3(rv, p.Connect)
+	rv = appendOp3(rv, p.Delete)
+	rv = appendOp3(rv, p.Get)
+	rv = appendOp3(rv, p.Head)
+	rv = appendOp3(rv, p.Options)
+	rv = appendOp3(rv, p.Patch)
+	rv = appendOp3(rv, p.Post)
+	rv = appendOp3(rv, p.Put)
+	rv = appendOp3(rv, p.Trace)
+	return rv
+}
+
+func appendOp3(ops []*openapi3.Operation, op *openapi3.Operation) []*openapi3.Operation {
+	if op == nil {
+		return ops
+	}
+	return append(ops, op)
+}
+
+// mixinFilesV3 is the OpenAPI 3.x implementation backing MixinFiles.
+func mixinFilesV3(primaryFile string, mixinFiles []string, w io.Writer, outOpts MixinFilesOpts) (uint, error) {
+	loader := openapi3.NewLoader()
+
+	primary, err := loader.LoadFromFile(primaryFile)
+	if err != nil {
+		return 0, err
+	}
+
+	var mixins []*openapi3.T
+	for _, mixinFile := range mixinFiles {
+		mixin, err := loader.LoadFromFile(mixinFile)
+		if err != nil {
+			return 0, err
+		}
+		mixins = append(mixins, mixin)
+	}
+
+	collisions := MixinV3(primary, mixins...)
+
+	if err := writeMerged(primary, w, outOpts, nil); err != nil {
+		return 0, fmt.Errorf("writing merged OpenAPI 3 document: %w", err)
+	}
+
+	return collisions, nil
+}
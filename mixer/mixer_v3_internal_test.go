@@ -0,0 +1,41 @@
+package mixer
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestIsOpenAPI3(t *testing.T) {
+	cases := []struct {
+		name     string
+		suffix   string
+		contents string
+		want     bool
+	}{
+		{"swagger2 json", ".json", `{"swagger": "2.0"}`, false},
+		{"openapi3 json", ".json", `{"openapi": "3.0.0"}`, true},
+		{"openapi3 yaml", ".yml", "openapi: 3.1.0\n", true},
+		{"swagger2 yaml", ".yml", "swagger: '2.0'\n", false},
+	}
+
+	for _, c := range cases {
+		f, err := ioutil.TempFile("", "mixer-version-probe-*"+c.suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(c.contents); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		got, err := isOpenAPI3(f.Name())
+		if err != nil {
+			t.Fatalf("%s: isOpenAPI3 returned error: %v", c.name, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: isOpenAPI3 = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package mixer_test
+
+import (
+	"testing"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/msample/swagger-mixin/mixer"
+)
+
+func TestMixinV3(t *testing.T) {
+	loader := openapi3.NewLoader()
+
+	primary, err := loader.LoadFromFile("../test-data/v3-primary.yml")
+	if err != nil {
+		t.Fatalf("Could not load ../test-data/v3-primary.yml: %v\n", err)
+	}
+	mixin1, err := loader.LoadFromFile("../test-data/v3-mixin1.yml")
+	if err != nil {
+		t.Fatalf("Could not load ../test-data/v3-mixin1.yml: %v\n", err)
+	}
+	mixin2, err := loader.LoadFromFile("../test-data/v3-mixin2.yml")
+	if err != nil {
+		t.Fatalf("Could not load ../test-data/v3-mixin2.yml: %v\n", err)
+	}
+
+	skipped := mixer.MixinV3(primary, mixin1, mixin2)
+	if skipped != 0 {
+		t.Errorf("TestMixinV3: expected 0 skipped, got %v\n", skipped)
+	}
+
+	if len(primary.Paths) != 3 {
+		t.Errorf("TestMixinV3: expected 3 paths in merged, got %v\n", len(primary.Paths))
+	}
+
+	if len(primary.Components.Schemas) != 2 {
+		t.Errorf("TestMixinV3: expected 2 schemas in merged, got %v\n", len(primary.Components.Schemas))
+	}
+
+	// mixin1's "/pb" operationId collides with primary's "/pa"
+	// operationId ("list"), so it should have been renamed rather than
+	// left duplicated.
+	pb, ok := primary.Paths["/pb"]
+	if !ok {
+		t.Fatal("TestMixinV3: expected \"/pb\" in merged paths")
+	}
+	if pb.Get.OperationID != "listMixin0" {
+		t.Errorf("TestMixinV3: expected \"/pb\" operationId to be renamed to \"listMixin0\", got %q\n", pb.Get.OperationID)
+	}
+
+	// mixin2 has no top level "components:" block at all, which used to
+	// panic with a nil pointer dereference.
+	pc, ok := primary.Paths["/pc"]
+	if !ok {
+		t.Fatal("TestMixinV3: expected \"/pc\" in merged paths")
+	}
+	if pc.Get.OperationID != "get" {
+		t.Errorf("TestMixinV3: expected \"/pc\" operationId to be unchanged, got %q\n", pc.Get.OperationID)
+	}
+}
@@ -0,0 +1,293 @@
+package mixer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// OutputFormat selects the serialization format MixinFiles writes.
+type OutputFormat string
+
+const (
+	// JSONFormat writes the merged spec as JSON. This is the default.
+	JSONFormat OutputFormat = "json"
+	// YAMLFormat writes the merged spec as YAML.
+	YAMLFormat OutputFormat = "yaml"
+)
+
+// MixinFilesOpts controls how MixinFiles serializes the merged spec.
+type MixinFilesOpts struct {
+	// Format selects JSON (the default, zero value) or YAML output.
+	Format OutputFormat
+	// Compact produces JSON without indentation. Ignored for YAML,
+	// which is never compacted onto a single line.
+	Compact bool
+	// KeepSpecOrder preserves the property ordering of "definitions",
+	// "parameters", "responses" and "paths" as they appeared in the
+	// primary and mixin source files, instead of the alphabetic
+	// ordering encoding/json applies to Go maps.
+	KeepSpecOrder bool
+}
+
+// keepOrderSections lists the top level map-valued sections that are
+// reordered when MixinFilesOpts.KeepSpecOrder is set.
+var keepOrderSections = []string{"definitions", "parameters", "responses", "paths"}
+
+// writeMerged marshals v (the merged primary spec) to w according to
+// outOpts. rawSources, when non-nil, provides the original raw bytes of
+// the primary followed by each mixin file in merge order, and is used
+// to compute the original property order for KeepSpecOrder.
+func writeMerged(v interface{}, w io.Writer, outOpts MixinFilesOpts, rawSources [][]byte) error {
+	bs, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if outOpts.KeepSpecOrder {
+		order, err := specKeySectionOrder(rawSources)
+		if err != nil {
+			return fmt.Errorf("computing spec key order: %w", err)
+		}
+		bs, err = applyKeepSpecOrder(bs, order)
+		if err != nil {
+			return fmt.Errorf("applying keep-spec-order: %w", err)
+		}
+	}
+
+	switch outOpts.Format {
+	case YAMLFormat:
+		val, err := jsonToYAMLValue(json.NewDecoder(bytes.NewReader(bs)))
+		if err != nil {
+			return err
+		}
+		out, err := yaml.Marshal(val)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(out)
+		return err
+	default:
+		if outOpts.Compact {
+			_, err := w.Write(bs)
+			return err
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, bs, "", "  "); err != nil {
+			return err
+		}
+		_, err := w.Write(buf.Bytes())
+		return err
+	}
+}
+
+// specKeySectionOrder computes, for each section in keepOrderSections,
+// the key order to use in the final output: the primary's own key
+// order (from rawSources[0]), followed by any new keys introduced by
+// each mixin (from rawSources[1:]) in the order the mixins were given,
+// skipping keys already seen.
+func specKeySectionOrder(rawSources [][]byte) (map[string][]string, error) {
+	order := make(map[string][]string, len(keepOrderSections))
+	seen := make(map[string]map[string]bool, len(keepOrderSections))
+	for _, section := range keepOrderSections {
+		seen[section] = make(map[string]bool)
+	}
+
+	for _, raw := range rawSources {
+		var top map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &top); err != nil {
+			return nil, err
+		}
+		for _, section := range keepOrderSections {
+			sub, ok := top[section]
+			if !ok {
+				continue
+			}
+			keys, err := objectKeyOrder(sub)
+			if err != nil {
+				return nil, err
+			}
+			for _, k := range keys {
+				if seen[section][k] {
+					continue
+				}
+				seen[section][k] = true
+				order[section] = append(order[section], k)
+			}
+		}
+	}
+	return order, nil
+}
+
+// applyKeepSpecOrder re-encodes bs (a marshaled spec) so that each
+// section named in order is emitted using the given key order, instead
+// of the alphabetic order encoding/json applies to Go maps. Keys
+// present in bs but missing from order (e.g. because rawSources wasn't
+// available for them) are appended at the end, sorted lexically.
+func applyKeepSpecOrder(bs []byte, order map[string][]string) ([]byte, error) {
+	topOrder, err := objectKeyOrder(bs)
+	if err != nil {
+		return nil, err
+	}
+
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(bs, &top); err != nil {
+		return nil, err
+	}
+
+	for section, keys := range order {
+		raw, ok := top[section]
+		if !ok || len(keys) == 0 {
+			continue
+		}
+		ordered, err := buildOrderedObject(raw, keys)
+		if err != nil {
+			return nil, err
+		}
+		top[section] = ordered
+	}
+
+	return buildOrderedObjectFromMap(top, topOrder)
+}
+
+// objectKeyOrder returns the top level keys of the JSON object in raw,
+// in the order they appear.
+func objectKeyOrder(raw json.RawMessage) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, nil
+	}
+
+	var keys []string
+	for dec.More() {
+		keyTok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, keyTok.(string))
+
+		// skip over the value without caring about its shape
+		var v json.RawMessage
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// buildOrderedObject decodes the JSON object in raw and re-encodes it
+// using the given key order. Keys present in raw but absent from keys
+// are appended at the end, sorted lexically.
+func buildOrderedObject(raw json.RawMessage, keys []string) (json.RawMessage, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return buildOrderedObjectFromMap(m, keys)
+}
+
+func buildOrderedObjectFromMap(m map[string]json.RawMessage, keys []string) (json.RawMessage, error) {
+	seen := make(map[string]bool, len(m))
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	write := func(k string) error {
+		v, ok := m[k]
+		if !ok {
+			return nil
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(v)
+		seen[k] = true
+		return nil
+	}
+	for _, k := range keys {
+		if err := write(k); err != nil {
+			return nil, err
+		}
+	}
+
+	// Keys present in m but absent from keys (e.g. names introduced by
+	// --rename-collisions, which never appear in the source files'
+	// order) have no stable order of their own; sort them lexically so
+	// the output doesn't depend on Go's randomized map iteration order.
+	var leftover []string
+	for k := range m {
+		if !seen[k] {
+			leftover = append(leftover, k)
+		}
+	}
+	sort.Strings(leftover)
+	for _, k := range leftover {
+		if err := write(k); err != nil {
+			return nil, err
+		}
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// jsonToYAMLValue decodes a single JSON value from dec into a tree of
+// plain values, yaml.MapSlice (for objects, preserving key order) and
+// []interface{} (for arrays), suitable for yaml.Marshal.
+func jsonToYAMLValue(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			var ms yaml.MapSlice
+			for dec.More() {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				val, err := jsonToYAMLValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				ms = append(ms, yaml.MapItem{Key: keyTok.(string), Value: val})
+			}
+			if _, err := dec.Token(); err != nil { // consume closing '}'
+				return nil, err
+			}
+			return ms, nil
+		case '[':
+			var arr []interface{}
+			for dec.More() {
+				val, err := jsonToYAMLValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, val)
+			}
+			if _, err := dec.Token(); err != nil { // consume closing ']'
+				return nil, err
+			}
+			return arr, nil
+		}
+		return nil, fmt.Errorf("unexpected JSON delimiter %v", t)
+	default:
+		return t, nil
+	}
+}
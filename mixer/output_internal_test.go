@@ -0,0 +1,71 @@
+package mixer
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestObjectKeyOrder(t *testing.T) {
+	keys, err := objectKeyOrder(json.RawMessage(`{"b": 1, "a": 2, "c": 3}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"b", "a", "c"}
+	if len(keys) != len(want) {
+		t.Fatalf("objectKeyOrder = %v, want %v", keys, want)
+	}
+	for i, k := range want {
+		if keys[i] != k {
+			t.Errorf("objectKeyOrder[%d] = %q, want %q", i, keys[i], k)
+		}
+	}
+}
+
+func TestBuildOrderedObject(t *testing.T) {
+	out, err := buildOrderedObject(json.RawMessage(`{"a": 1, "b": 2, "c": 3}`), []string{"c", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// "c" and "a" should come first, in that order, followed by the
+	// leftover "b".
+	keys, err := objectKeyOrder(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"c", "a", "b"}
+	if len(keys) != len(want) {
+		t.Fatalf("buildOrderedObject key order = %v, want %v", keys, want)
+	}
+	for i, k := range want {
+		if keys[i] != k {
+			t.Errorf("buildOrderedObject key order[%d] = %q, want %q", i, keys[i], k)
+		}
+	}
+}
+
+func TestBuildOrderedObjectLeftoverKeysAreSorted(t *testing.T) {
+	// Several leftover keys (e.g. names introduced by
+	// --rename-collisions, which never appear in the given key order)
+	// must come out in a deterministic order, not Go's randomized map
+	// iteration order.
+	raw := json.RawMessage(`{"D1Mixin1": 1, "D1Mixin0": 2, "z": 3, "a": 4}`)
+	for i := 0; i < 20; i++ {
+		out, err := buildOrderedObject(raw, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys, err := objectKeyOrder(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"D1Mixin0", "D1Mixin1", "a", "z"}
+		if len(keys) != len(want) {
+			t.Fatalf("buildOrderedObject key order = %v, want %v", keys, want)
+		}
+		for i, k := range want {
+			if keys[i] != k {
+				t.Errorf("buildOrderedObject key order[%d] = %q, want %q", i, keys[i], k)
+			}
+		}
+	}
+}
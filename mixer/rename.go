@@ -0,0 +1,266 @@
+package mixer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/go-openapi/spec"
+)
+
+// specEqual reports whether a and b are structurally identical, by
+// marshaling both to canonical JSON and comparing the bytes. Used to
+// tell a harmless duplicate (same name, identical definition) apart
+// from a genuine name collision.
+func specEqual(a, b interface{}) bool {
+	ab, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	bb, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ab, bb)
+}
+
+// applyRenameOnCollision walks mixin before it is merged into primary,
+// renaming any colliding "definitions", "parameters" or "responses"
+// entry to a unique "<Name>Mixin<N>" and rewriting every $ref that
+// points at it, so the entry can be kept instead of skipped.
+//
+// An entry is only renamed if it actually differs (per specEqual) from
+// the one already present in primary (or a higher priority mixin
+// already merged in); structurally identical entries are simply
+// dropped from mixin so the existing name is reused. Since a dropped
+// duplicate never reaches the main merge loop in MixinWithOptions, it
+// is recorded here (as an Equal:true Collision) so it isn't silently
+// missing from the returned collision list compared to SkipOnCollision
+// and ContinueOnCollision.
+func applyRenameOnCollision(primary *spec.Swagger, mixin *spec.Swagger, mixinIndex int) []Collision {
+	var collisions []Collision
+	renames := make(map[string]string)
+
+	for _, k := range definitionKeys(mixin) {
+		existing, exists := primary.Definitions[k]
+		if !exists {
+			continue
+		}
+		if specEqual(existing, mixin.Definitions[k]) {
+			collisions = append(collisions, Collision{Section: "definitions", Key: k, Reason: "already exists in primary or higher priority mixin", Equal: true})
+			delete(mixin.Definitions, k)
+			continue
+		}
+		newKey := uniqueName(k, mixinIndex, func(n string) bool {
+			if _, taken := primary.Definitions[n]; taken {
+				return true
+			}
+			_, taken := mixin.Definitions[n]
+			return taken
+		})
+		mixin.Definitions[newKey] = mixin.Definitions[k]
+		delete(mixin.Definitions, k)
+		renames[refPointer("definitions", k)] = refPointer("definitions", newKey)
+	}
+
+	for _, k := range parameterKeys(mixin) {
+		existing, exists := primary.Parameters[k]
+		if !exists {
+			continue
+		}
+		if specEqual(existing, mixin.Parameters[k]) {
+			collisions = append(collisions, Collision{Section: "parameters", Key: k, Reason: "already exists in primary or higher priority mixin", Equal: true})
+			delete(mixin.Parameters, k)
+			continue
+		}
+		newKey := uniqueName(k, mixinIndex, func(n string) bool {
+			if _, taken := primary.Parameters[n]; taken {
+				return true
+			}
+			_, taken := mixin.Parameters[n]
+			return taken
+		})
+		mixin.Parameters[newKey] = mixin.Parameters[k]
+		delete(mixin.Parameters, k)
+		renames[refPointer("parameters", k)] = refPointer("parameters", newKey)
+	}
+
+	for _, k := range responseKeys(mixin) {
+		existing, exists := primary.Responses[k]
+		if !exists {
+			continue
+		}
+		if specEqual(existing, mixin.Responses[k]) {
+			collisions = append(collisions, Collision{Section: "responses", Key: k, Reason: "already exists in primary or higher priority mixin", Equal: true})
+			delete(mixin.Responses, k)
+			continue
+		}
+		newKey := uniqueName(k, mixinIndex, func(n string) bool {
+			if _, taken := primary.Responses[n]; taken {
+				return true
+			}
+			_, taken := mixin.Responses[n]
+			return taken
+		})
+		mixin.Responses[newKey] = mixin.Responses[k]
+		delete(mixin.Responses, k)
+		renames[refPointer("responses", k)] = refPointer("responses", newKey)
+	}
+
+	if len(renames) > 0 {
+		rewriteMixinRefs(mixin, renames)
+	}
+	return collisions
+}
+
+// uniqueName returns "<base>Mixin<mixinIndex>", suffixing with an
+// increasing counter in the unlikely case that name is also taken.
+// taken is expected to check both primary's and mixin's own key set,
+// so a rename can never silently overwrite an unrelated entry the
+// mixin already had under that name (e.g. a prior rename-on-collision
+// merge having produced a literal "FooMixin0").
+func uniqueName(base string, mixinIndex int, taken func(string) bool) string {
+	name := fmt.Sprintf("%vMixin%v", base, mixinIndex)
+	for n := 1; taken(name); n++ {
+		name = fmt.Sprintf("%vMixin%v_%v", base, mixinIndex, n)
+	}
+	return name
+}
+
+func refPointer(section, key string) string {
+	return fmt.Sprintf("#/%v/%v", section, key)
+}
+
+func definitionKeys(m *spec.Swagger) []string {
+	keys := make([]string, 0, len(m.Definitions))
+	for k := range m.Definitions {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func parameterKeys(m *spec.Swagger) []string {
+	keys := make([]string, 0, len(m.Parameters))
+	for k := range m.Parameters {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func responseKeys(m *spec.Swagger) []string {
+	keys := make([]string, 0, len(m.Responses))
+	for k := range m.Responses {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// rewriteMixinRefs walks every $ref reachable from mixin (paths'
+// operation parameters/responses/schemas, definitions' nested schemas,
+// and the top level parameters/responses) and rewrites any that match
+// a key in renames.
+func rewriteMixinRefs(mixin *spec.Swagger, renames map[string]string) {
+	for k, v := range mixin.Definitions {
+		walkSchemaRefs(&v, renames)
+		mixin.Definitions[k] = v
+	}
+	for k, v := range mixin.Parameters {
+		walkParamRefs(&v, renames)
+		mixin.Parameters[k] = v
+	}
+	for k, v := range mixin.Responses {
+		walkResponseRefs(&v, renames)
+		mixin.Responses[k] = v
+	}
+	for k, v := range mixin.Paths.Paths {
+		for i := range v.Parameters {
+			walkParamRefs(&v.Parameters[i], renames)
+		}
+		ops := pathItemOps(v)
+		if v.Options != nil {
+			ops = append(ops, v.Options)
+		}
+		for _, op := range ops {
+			for i := range op.Parameters {
+				walkParamRefs(&op.Parameters[i], renames)
+			}
+			if op.Responses != nil {
+				walkResponsesRefs(op.Responses, renames)
+			}
+		}
+		mixin.Paths.Paths[k] = v
+	}
+}
+
+func walkResponsesRefs(r *spec.Responses, renames map[string]string) {
+	if r.Default != nil {
+		walkResponseRefs(r.Default, renames)
+	}
+	for code, resp := range r.StatusCodeResponses {
+		walkResponseRefs(&resp, renames)
+		r.StatusCodeResponses[code] = resp
+	}
+}
+
+func walkParamRefs(p *spec.Parameter, renames map[string]string) {
+	rewriteRef(&p.Ref, renames)
+	if p.Schema != nil {
+		walkSchemaRefs(p.Schema, renames)
+	}
+}
+
+func walkResponseRefs(r *spec.Response, renames map[string]string) {
+	rewriteRef(&r.Ref, renames)
+	if r.Schema != nil {
+		walkSchemaRefs(r.Schema, renames)
+	}
+}
+
+func walkSchemaRefs(s *spec.Schema, renames map[string]string) {
+	if s == nil {
+		return
+	}
+	rewriteRef(&s.Ref, renames)
+	for k, v := range s.Properties {
+		walkSchemaRefs(&v, renames)
+		s.Properties[k] = v
+	}
+	if s.Items != nil {
+		if s.Items.Schema != nil {
+			walkSchemaRefs(s.Items.Schema, renames)
+		}
+		for i := range s.Items.Schemas {
+			walkSchemaRefs(&s.Items.Schemas[i], renames)
+		}
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+		walkSchemaRefs(s.AdditionalProperties.Schema, renames)
+	}
+	for i := range s.AllOf {
+		walkSchemaRefs(&s.AllOf[i], renames)
+	}
+	for i := range s.OneOf {
+		walkSchemaRefs(&s.OneOf[i], renames)
+	}
+	for i := range s.AnyOf {
+		walkSchemaRefs(&s.AnyOf[i], renames)
+	}
+	if s.Not != nil {
+		walkSchemaRefs(s.Not, renames)
+	}
+}
+
+// rewriteRef replaces ref with its renamed target, if renames has an
+// entry for ref's current pointer.
+func rewriteRef(ref *spec.Ref, renames map[string]string) {
+	if ref == nil {
+		return
+	}
+	cur := ref.String()
+	if cur == "" {
+		return
+	}
+	if newRef, ok := renames[cur]; ok {
+		*ref = spec.MustCreateRef(newRef)
+	}
+}